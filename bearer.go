@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tete1030/go-modemmanager"
+)
+
+var (
+	bearerLabels = []string{"imei", "bearer", "apn", "ip_family"}
+	dnsLabels    = []string{"imei", "bearer", "apn", "server", "family"}
+
+	bearerRxBytesTotal = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bearer", "rx_bytes_total"),
+		"Cumulative bytes received over this bearer, accumulated across disconnect/reconnect so it never appears to reset",
+		bearerLabels, nil,
+	)
+
+	bearerTxBytesTotal = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bearer", "tx_bytes_total"),
+		"Cumulative bytes transmitted over this bearer, accumulated across disconnect/reconnect so it never appears to reset",
+		bearerLabels, nil,
+	)
+
+	bearerDurationSecondsTotal = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bearer", "duration_seconds_total"),
+		"Cumulative time this bearer has spent connected, accumulated across disconnect/reconnect",
+		bearerLabels, nil,
+	)
+
+	bearerConnected = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bearer", "connected"),
+		"Is this bearer currently connected",
+		bearerLabels, nil,
+	)
+
+	bearerMtuBytes = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bearer", "mtu_bytes"),
+		"MTU negotiated for this bearer's IP connection, as dictated by the cellular network",
+		bearerLabels, nil,
+	)
+
+	bearerDnsInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bearer", "dns_info"),
+		"DNS server pushed down by the network for this bearer",
+		dnsLabels, nil,
+	)
+)
+
+// bearerAccumulator turns the raw, bearer-scoped counters ModemManager
+// reports into Prometheus counters that keep climbing across a
+// disconnect/reconnect, by tracking each bearer's D-Bus object path and
+// folding its last known value into a running base once it is replaced
+// by a new bearer.
+type bearerAccumulator struct {
+	mu    sync.Mutex
+	state map[string]*bearerAccumulatorState
+}
+
+type bearerAccumulatorState struct {
+	bearerPath                  string
+	baseRx, baseTx, baseSeconds float64
+	lastRx, lastTx, lastSeconds float64
+}
+
+func newBearerAccumulator() *bearerAccumulator {
+	return &bearerAccumulator{state: make(map[string]*bearerAccumulatorState)}
+}
+
+// observe folds in a new raw sample for the bearer identified by
+// bearerPath under seriesKey (its imei, bearer type and APN; ip_family
+// is not part of the key) and returns the cumulative totals to export.
+func (ba *bearerAccumulator) observe(seriesKey, bearerPath string, rx, tx, seconds float64) (totalRx, totalTx, totalSeconds float64) {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+
+	s, ok := ba.state[seriesKey]
+	if !ok {
+		s = &bearerAccumulatorState{bearerPath: bearerPath}
+		ba.state[seriesKey] = s
+	} else if s.bearerPath != bearerPath {
+		// The bearer behind this series was torn down and recreated
+		// (e.g. SIM switch, modem reconnect): keep what it had
+		// accumulated so the exported counter doesn't dip.
+		s.baseRx += s.lastRx
+		s.baseTx += s.lastTx
+		s.baseSeconds += s.lastSeconds
+		s.bearerPath = bearerPath
+		s.lastRx, s.lastTx, s.lastSeconds = 0, 0, 0
+	}
+
+	if rx < s.lastRx {
+		s.baseRx += s.lastRx
+	}
+	if tx < s.lastTx {
+		s.baseTx += s.lastTx
+	}
+	if seconds < s.lastSeconds {
+		s.baseSeconds += s.lastSeconds
+	}
+	s.lastRx, s.lastTx, s.lastSeconds = rx, tx, seconds
+
+	return s.baseRx + rx, s.baseTx + tx, s.baseSeconds + seconds
+}
+
+// collectBearers emits the bearer counters and gauges described above
+// for every bearer currently attached to modem.
+func collectBearers(ch chan<- prometheus.Metric, acc *bearerAccumulator, modem modemmanager.Modem, imei string) {
+	bearers, err := modem.GetBearers()
+	if err != nil {
+		log.Println("error getting bearers:", err)
+		return
+	}
+
+	for _, bearer := range bearers {
+		bearerType, err := bearer.GetBearerType()
+		if err != nil {
+			log.Println("error getting bearer type:", err)
+			bearerType = modemmanager.MmBearerTypeUnknown
+		}
+		bearerLabel := bearerType.String()
+
+		properties, err := bearer.GetProperties()
+		if err != nil {
+			log.Println("error getting bearer properties:", err)
+		}
+		apn := properties.APN
+
+		isConnected, err := bearer.GetConnected()
+		if err != nil {
+			log.Println("error getting bearer connected state:", err)
+		}
+		connectedValue := 0.0
+		if isConnected {
+			connectedValue = 1
+		}
+
+		bearerPath := string(bearer.GetObjectPath())
+
+		ip4Config, ip4Err := bearer.GetIp4Config()
+		ip6Config, ip6Err := bearer.GetIp6Config()
+
+		ipFamily := ""
+		switch {
+		case ip4Err == nil && ip6Err == nil:
+			ipFamily = "ipv4v6"
+		case ip4Err == nil:
+			ipFamily = "ipv4"
+		case ip6Err == nil:
+			ipFamily = "ipv6"
+		}
+
+		seriesKey := fmt.Sprintf("%s|%s|%s", imei, bearerLabel, apn)
+
+		stats, err := bearer.GetStats()
+		if err != nil {
+			log.Println("error getting bearer stats:", err)
+		} else {
+			rxTotal, txTotal, durationTotal := acc.observe(seriesKey, bearerPath, float64(stats.RxBytes), float64(stats.TxBytes), float64(stats.Duration))
+
+			ch <- prometheus.MustNewConstMetric(bearerRxBytesTotal, prometheus.CounterValue, rxTotal, imei, bearerLabel, apn, ipFamily)
+			ch <- prometheus.MustNewConstMetric(bearerTxBytesTotal, prometheus.CounterValue, txTotal, imei, bearerLabel, apn, ipFamily)
+			ch <- prometheus.MustNewConstMetric(bearerDurationSecondsTotal, prometheus.CounterValue, durationTotal, imei, bearerLabel, apn, ipFamily)
+		}
+
+		ch <- prometheus.MustNewConstMetric(bearerConnected, prometheus.GaugeValue, connectedValue, imei, bearerLabel, apn, ipFamily)
+
+		if ip4Err == nil {
+			ch <- prometheus.MustNewConstMetric(bearerMtuBytes, prometheus.GaugeValue, float64(ip4Config.Mtu), imei, bearerLabel, apn, "ipv4")
+			for _, server := range []string{ip4Config.Dns1, ip4Config.Dns2, ip4Config.Dns3} {
+				if server != "" {
+					ch <- prometheus.MustNewConstMetric(bearerDnsInfo, prometheus.GaugeValue, 1, imei, bearerLabel, apn, server, "ipv4")
+				}
+			}
+		}
+
+		if ip6Err == nil {
+			ch <- prometheus.MustNewConstMetric(bearerMtuBytes, prometheus.GaugeValue, float64(ip6Config.Mtu), imei, bearerLabel, apn, "ipv6")
+			for _, server := range []string{ip6Config.Dns1, ip6Config.Dns2, ip6Config.Dns3} {
+				if server != "" {
+					ch <- prometheus.MustNewConstMetric(bearerDnsInfo, prometheus.GaugeValue, 1, imei, bearerLabel, apn, server, "ipv6")
+				}
+			}
+		}
+	}
+}
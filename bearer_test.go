@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestBearerAccumulatorObserve(t *testing.T) {
+	acc := newBearerAccumulator()
+
+	rx, tx, secs := acc.observe("imei1|wwan|internet", "/bearer/0", 100, 50, 10)
+	if rx != 100 || tx != 50 || secs != 10 {
+		t.Fatalf("first sample: got (%v, %v, %v), want (100, 50, 10)", rx, tx, secs)
+	}
+
+	rx, tx, secs = acc.observe("imei1|wwan|internet", "/bearer/0", 150, 80, 20)
+	if rx != 150 || tx != 80 || secs != 20 {
+		t.Fatalf("climbing sample: got (%v, %v, %v), want (150, 80, 20)", rx, tx, secs)
+	}
+
+	// Same bearer path but counters dropped (e.g. ModemManager restart
+	// reset them without tearing the bearer down): base should absorb
+	// what was already reported so the exported total never dips.
+	rx, tx, secs = acc.observe("imei1|wwan|internet", "/bearer/0", 10, 5, 2)
+	if rx != 160 || tx != 85 || secs != 22 {
+		t.Fatalf("counter reset: got (%v, %v, %v), want (160, 85, 22)", rx, tx, secs)
+	}
+
+	// Bearer torn down and recreated under the same series key
+	// (different object path): base should fold in the last known
+	// values for the old bearer before starting the new one from zero.
+	rx, tx, secs = acc.observe("imei1|wwan|internet", "/bearer/1", 5, 1, 1)
+	if rx != 165 || tx != 86 || secs != 23 {
+		t.Fatalf("bearer replaced: got (%v, %v, %v), want (165, 86, 23)", rx, tx, secs)
+	}
+
+	// A distinct series key (different imei/bearer/apn) must not share
+	// accumulated state with the first one.
+	rx, tx, secs = acc.observe("imei2|wwan|internet", "/bearer/0", 7, 3, 1)
+	if rx != 7 || tx != 3 || secs != 1 {
+		t.Fatalf("distinct series: got (%v, %v, %v), want (7, 3, 1)", rx, tx, secs)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tete1030/go-modemmanager"
+	"gopkg.in/yaml.v2"
+)
+
+var configReloadsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "Number of times the config file has been reloaded, by result",
+	},
+	[]string{"result"},
+)
+
+// ModemConfig holds the per-modem settings read from the config file.
+// A modem is matched against this map by IMEI or by its stable port
+// address (see identity.go).
+type ModemConfig struct {
+	APN         string `yaml:"apn"`
+	User        string `yaml:"user"`
+	Password    string `yaml:"password"`
+	AllowedAuth string `yaml:"allowed_auth"` // "pap", "chap", "none", or "" for modem default
+	IPType      string `yaml:"ip_type"`      // "ipv4", "ipv6", "ipv4v6", or "" for modem default
+	SIMSlot     uint32 `yaml:"sim_slot"`
+	Name        string `yaml:"name"`
+}
+
+// TargetConfig marks a name used with /probe?target=<key> as referring to
+// a remote ModemManager instance. github.com/tete1030/go-modemmanager (as
+// pinned, v0.1.6) has no way to dial anything but the local D-Bus system
+// bus, so a target listed here can never actually be probed; its only
+// purpose is to let probeHandler reject it with a clear error instead of
+// silently probing the local modem under the wrong target label.
+type TargetConfig struct {
+}
+
+// Config is the top level structure of --config.file. Modems are keyed
+// by whatever identifier the user finds stable: IMEI or a USB/PCI port
+// address. Targets are keyed by whatever name the
+// Prometheus scrape config passes as ?target=.
+type Config struct {
+	Modems  map[string]ModemConfig  `yaml:"modems"`
+	Targets map[string]TargetConfig `yaml:"targets"`
+}
+
+// toSimpleProperties converts a ModemConfig into the properties
+// expected by modemmanager's Simple.Connect.
+func (mc ModemConfig) toSimpleProperties() modemmanager.SimpleProperties {
+	props := modemmanager.SimpleProperties{
+		Apn:      mc.APN,
+		User:     mc.User,
+		Password: mc.Password,
+	}
+
+	switch strings.ToLower(mc.AllowedAuth) {
+	case "pap":
+		props.AllowedAuth = modemmanager.MmBearerAllowedAuthPap
+	case "chap":
+		props.AllowedAuth = modemmanager.MmBearerAllowedAuthChap
+	case "none":
+		props.AllowedAuth = modemmanager.MmBearerAllowedAuthNone
+	}
+
+	switch strings.ToLower(mc.IPType) {
+	case "ipv4":
+		props.IpType = modemmanager.MmBearerIpFamilyIpv4
+	case "ipv6":
+		props.IpType = modemmanager.MmBearerIpFamilyIpv6
+	case "ipv4v6":
+		props.IpType = modemmanager.MmBearerIpFamilyIpv4v6
+	}
+
+	return props
+}
+
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	c := &Config{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("parsing config file: %w", err)
+	}
+
+	return c, nil
+}
+
+// safeConfig wraps a Config behind a mutex so it can be swapped in
+// place by watchConfig while Exporter.Collect and influxHandler are
+// reading it concurrently.
+type safeConfig struct {
+	sync.RWMutex
+	C *Config
+}
+
+// Reload re-reads the config file and, on success, atomically replaces
+// the held config. On failure the previous good config is kept.
+func (sc *safeConfig) Reload(path string) error {
+	c, err := loadConfig(path)
+	if err != nil {
+		configReloadsTotal.WithLabelValues("failure").Inc()
+		return err
+	}
+
+	sc.Lock()
+	sc.C = c
+	sc.Unlock()
+
+	configReloadsTotal.WithLabelValues("success").Inc()
+	return nil
+}
+
+// lookup finds the config for a modem by trying each identifier in
+// turn (e.g. port address first, then IMEI), returning the first match.
+func (sc *safeConfig) lookup(identifiers ...string) (ModemConfig, bool) {
+	sc.RLock()
+	defer sc.RUnlock()
+
+	if sc.C == nil {
+		return ModemConfig{}, false
+	}
+
+	for _, id := range identifiers {
+		if id == "" {
+			continue
+		}
+		if mc, ok := sc.C.Modems[id]; ok {
+			return mc, true
+		}
+	}
+
+	return ModemConfig{}, false
+}
+
+// isRemoteTarget reports whether target is configured as a remote
+// ModemManager instance, which probeHandler cannot actually reach.
+func (sc *safeConfig) isRemoteTarget(target string) bool {
+	sc.RLock()
+	defer sc.RUnlock()
+
+	if sc.C == nil {
+		return false
+	}
+	_, ok := sc.C.Targets[target]
+	return ok
+}
+
+// watchConfig follows the pattern used by mysqld_exporter's watchConfig:
+// it watches the directory containing the config file (rather than the
+// file itself) since editors and config management tools commonly
+// replace the file instead of writing to it in place.
+func watchConfig(sc *safeConfig, path string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Println("cannot start config watcher:", err)
+		return
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		log.Println("cannot watch config directory:", err)
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(path) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if err := sc.Reload(path); err != nil {
+				log.Println("error reloading config, keeping previous config:", err)
+			} else {
+				log.Println("config reloaded successfully")
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("config watcher error:", err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tete1030/go-modemmanager"
+)
+
+func TestModemConfigToSimpleProperties(t *testing.T) {
+	cases := []struct {
+		name string
+		mc   ModemConfig
+		want modemmanager.SimpleProperties
+	}{
+		{
+			name: "defaults left unset",
+			mc:   ModemConfig{APN: "internet", User: "u", Password: "p"},
+			want: modemmanager.SimpleProperties{Apn: "internet", User: "u", Password: "p"},
+		},
+		{
+			name: "pap auth and ipv4v6",
+			mc:   ModemConfig{APN: "internet", AllowedAuth: "PAP", IPType: "IPV4V6"},
+			want: modemmanager.SimpleProperties{
+				Apn:         "internet",
+				AllowedAuth: modemmanager.MmBearerAllowedAuthPap,
+				IpType:      modemmanager.MmBearerIpFamilyIpv4v6,
+			},
+		},
+		{
+			name: "chap auth and ipv6",
+			mc:   ModemConfig{APN: "internet", AllowedAuth: "chap", IPType: "ipv6"},
+			want: modemmanager.SimpleProperties{
+				Apn:         "internet",
+				AllowedAuth: modemmanager.MmBearerAllowedAuthChap,
+				IpType:      modemmanager.MmBearerIpFamilyIpv6,
+			},
+		},
+		{
+			name: "none auth and ipv4",
+			mc:   ModemConfig{APN: "internet", AllowedAuth: "none", IPType: "ipv4"},
+			want: modemmanager.SimpleProperties{
+				Apn:         "internet",
+				AllowedAuth: modemmanager.MmBearerAllowedAuthNone,
+				IpType:      modemmanager.MmBearerIpFamilyIpv4,
+			},
+		},
+		{
+			name: "unknown auth and ip type ignored",
+			mc:   ModemConfig{APN: "internet", AllowedAuth: "bogus", IPType: "bogus"},
+			want: modemmanager.SimpleProperties{Apn: "internet"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.mc.toSimpleProperties(); got != tc.want {
+				t.Errorf("toSimpleProperties() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tete1030/go-modemmanager"
+)
+
+var (
+	// usbPortAddressRe matches sysfs path components of the form
+	// "usb1" or "1-1.2", which together identify a USB device's
+	// physical port and stay stable across reboots and SIM swaps.
+	usbPortAddressRe = regexp.MustCompile(`^usb\d+$|^\d+(-\d+)+(\.\d+)*$`)
+
+	// pciAddressRe matches sysfs path components of the form
+	// "0000:03:00.0".
+	pciAddressRe = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-9a-f]$`)
+
+	modemInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "info"),
+		"Static information about a modem, labelled by its stable identity",
+		modemlabels, nil,
+	)
+)
+
+// resolvePortAddress derives a stable USB or PCI bus address for modem
+// by resolving its primary device's sysfs path, mirroring how EVE's
+// cellular microservice identifies modems independent of the
+// nondeterministic order they initialize in at boot.
+func resolvePortAddress(modem modemmanager.Modem) (string, error) {
+	device, err := modem.GetDevice()
+	if err != nil {
+		return "", err
+	}
+
+	realPath, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		// Device may not be a symlink into /sys (e.g. in tests or
+		// containers without sysfs mounted); fall back to the raw
+		// path and still try to extract an address from it.
+		realPath = device
+	}
+
+	return addressFromSysfsPath(realPath), nil
+}
+
+// addressFromSysfsPath walks a resolved sysfs path from its deepest
+// component outward, looking for the first one that looks like a PCI
+// or USB port address.
+func addressFromSysfsPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if pciAddressRe.MatchString(parts[i]) {
+			return parts[i]
+		}
+		if usbPortAddressRe.MatchString(parts[i]) {
+			return parts[i]
+		}
+	}
+
+	return ""
+}
+
+// modemIdentity resolves the stable port address for modem and, if the
+// user has pinned a friendly name to that address in the config file,
+// prefers that name when labelling metrics. Falls back to the port
+// address itself, and finally to imei, when no name is pinned.
+func modemIdentity(modem modemmanager.Modem, imei string, config *safeConfig) (name string, port string) {
+	port, err := resolvePortAddress(modem)
+	if err != nil {
+		log.Println("error resolving modem port address:", err)
+	}
+
+	if modemConfig, ok := config.lookup(port, imei); ok && modemConfig.Name != "" {
+		name = modemConfig.Name
+	} else if port != "" {
+		name = port
+	} else {
+		name = imei
+	}
+
+	return name, port
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestAddressFromSysfsPath(t *testing.T) {
+	cases := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"usb port", "/sys/devices/platform/soc/usb1/1-1/1-1.2", "1-1.2"},
+		{"usb root hub", "/sys/devices/platform/soc/usb1", "usb1"},
+		{"pci address", "/sys/devices/pci0000:00/0000:00:1c.0/0000:03:00.0", "0000:03:00.0"},
+		{"no match", "/sys/devices/virtual/foo/bar", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := addressFromSysfsPath(tc.path); got != tc.want {
+				t.Errorf("addressFromSysfsPath(%q) = %q, want %q", tc.path, got, tc.want)
+			}
+		})
+	}
+}
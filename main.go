@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -19,12 +18,14 @@ const namespace = "modem"
 const subsystem = ""
 
 var (
-	modemlabels = []string{"imei", "icc", "imsi", "operatorid", "operator", "v_operator", "rat"}
+	modemlabels = []string{"modem", "port", "imei", "icc", "imsi", "operatorid", "operator", "v_operator", "rat"}
 
 	listenAddress = flag.String("web.listen-address", ":9898",
 		"Address to listen on for telemetry")
 	metricsPath = flag.String("web.telemetry-path", "/metrics",
 		"Path under which to expose metrics")
+	configFile = flag.String("config.file", "",
+		"Path to a config file mapping modem identifiers to APN/credentials. Hot-reloaded on change")
 
 	up = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, subsystem, "up"),
@@ -88,12 +89,16 @@ var (
 )
 
 type Exporter struct {
-	mmgr modemmanager.ModemManager
+	mmgr    modemmanager.ModemManager
+	config  *safeConfig
+	bearers *bearerAccumulator
 }
 
-func NewExporter(mmgr modemmanager.ModemManager) *Exporter {
+func NewExporter(mmgr modemmanager.ModemManager, config *safeConfig) *Exporter {
 	return &Exporter{
-		mmgr: mmgr,
+		mmgr:    mmgr,
+		config:  config,
+		bearers: newBearerAccumulator(),
 	}
 }
 
@@ -103,6 +108,56 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- rssi
 	ch <- rsrp
 	ch <- roaming
+	ch <- simSlotActive
+	ch <- simSlotPresent
+	ch <- simSlotConfigured
+	ch <- modemInfo
+	ch <- ratSupported
+	ch <- ratPreferred
+	ch <- ratCurrent
+	ch <- bandCurrent
+	ch <- bearerRxBytesTotal
+	ch <- bearerTxBytesTotal
+	ch <- bearerDurationSecondsTotal
+	ch <- bearerConnected
+	ch <- bearerMtuBytes
+	ch <- bearerDnsInfo
+}
+
+// connectIfConfigured brings up a bearer for modem using the APN and
+// credentials configured for its port address or imei, if any. It is a
+// no-op when the modem has no matching config entry or the entry has no
+// APN set.
+func connectIfConfigured(modem modemmanager.Modem, imei string, config *safeConfig) {
+	port, _ := resolvePortAddress(modem)
+	modemConfig, ok := config.lookup(port, imei)
+	if !ok || modemConfig.APN == "" {
+		return
+	}
+
+	bearers, _ := modem.GetBearers()
+
+	// delete all bearer - if registered but no bearer something is likely wrong
+	for _, bearer := range bearers {
+		bearer.Disconnect()
+		if err := modem.DeleteBearer(bearer); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+
+	modemSimple, err := modem.GetSimpleModem()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	newBearer, err := modemSimple.Connect(modemConfig.toSimpleProperties())
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	fmt.Println("New Bearer: ", newBearer)
 }
 
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
@@ -181,6 +236,12 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 			continue
 		}
 
+		modemName, port := modemIdentity(modem, imei, e.config)
+
+		collectSimSlots(ch, modem, imei, e.config)
+		collectRatAndBand(ch, modem, imei)
+		collectBearers(ch, e.bearers, modem, imei)
+
 		ratList, err := modem.GetAccessTechnologies()
 		if err != nil {
 			log.Println(err)
@@ -194,6 +255,10 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 
 		rat := strings.ToLower(ratList[0].String())
 
+		ch <- prometheus.MustNewConstMetric(
+			modemInfo, prometheus.GaugeValue, 1, modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+		)
+
 		state, err = modem.GetState()
 		if err != nil {
 			log.Println("cannot get modem state:" + err.Error())
@@ -202,57 +267,26 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 
 		// if we are registered, we should try to connect
 		if state.String() == "Registered" {
-
-			apn := os.Getenv("MODEM_EXPORTER_APN")
-
-			if apn != "" {
-
-				bearers, _ := modem.GetBearers()
-
-				// delete all bearer - if registered but no bearer something is likely wrong
-				for _, bearer := range bearers {
-					bearer.Disconnect()
-					err = modem.DeleteBearer(bearer)
-					if err != nil {
-						log.Println(err)
-						continue
-					}
-				}
-
-				modemSimple, err := modem.GetSimpleModem()
-				if err != nil {
-					log.Println(err)
-				} else {
-					property := modemmanager.SimpleProperties{Apn: apn}
-					newBearer, err := modemSimple.Connect(property)
-					if err != nil {
-						log.Println(err)
-					} else {
-						fmt.Println("New Bearer: ", newBearer)
-					}
-				}
-
-			}
-
+			connectIfConfigured(modem, imei, e.config)
 		}
 
 		if state.String() == "Registered" || state.String() == "Connected" {
 			ch <- prometheus.MustNewConstMetric(
-				registered, prometheus.GaugeValue, 1, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				registered, prometheus.GaugeValue, 1, modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 		} else {
 			ch <- prometheus.MustNewConstMetric(
-				registered, prometheus.GaugeValue, 0, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				registered, prometheus.GaugeValue, 0, modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 		}
 
 		if state.String() == "Connected" {
 			ch <- prometheus.MustNewConstMetric(
-				connected, prometheus.GaugeValue, 1, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				connected, prometheus.GaugeValue, 1, modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 		} else {
 			ch <- prometheus.MustNewConstMetric(
-				connected, prometheus.GaugeValue, 0, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				connected, prometheus.GaugeValue, 0, modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 		}
 
@@ -279,7 +313,7 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 
 		if decCellID, err := strconv.ParseInt(cellID, 16, 64); err == nil {
 			ch <- prometheus.MustNewConstMetric(
-				cellid, prometheus.GaugeValue, float64(decCellID), imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				cellid, prometheus.GaugeValue, float64(decCellID), modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 		} else {
 			log.Println(err)
@@ -288,7 +322,7 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 		lAC := mloc.ThreeGppLacCi.Lac
 		if decLAC, err := strconv.ParseInt(lAC, 16, 64); err == nil {
 			ch <- prometheus.MustNewConstMetric(
-				lac, prometheus.GaugeValue, float64(decLAC), imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				lac, prometheus.GaugeValue, float64(decLAC), modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 		} else {
 			log.Println(err)
@@ -297,7 +331,7 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 		tAC := mloc.ThreeGppLacCi.Tac
 		if decTAC, err := strconv.ParseInt(tAC, 16, 64); err == nil {
 			ch <- prometheus.MustNewConstMetric(
-				tac, prometheus.GaugeValue, float64(decTAC), imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				tac, prometheus.GaugeValue, float64(decTAC), modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 		} else {
 			log.Println(err)
@@ -311,11 +345,11 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 
 		if regState.String() == "Roaming" {
 			ch <- prometheus.MustNewConstMetric(
-				roaming, prometheus.GaugeValue, 1, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				roaming, prometheus.GaugeValue, 1, modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 		} else {
 			ch <- prometheus.MustNewConstMetric(
-				roaming, prometheus.GaugeValue, 0, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				roaming, prometheus.GaugeValue, 0, modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 		}
 
@@ -327,7 +361,7 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 
 		if s, err := strconv.ParseFloat(opCode, 64); err == nil {
 			ch <- prometheus.MustNewConstMetric(
-				operatorcode, prometheus.GaugeValue, s, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				operatorcode, prometheus.GaugeValue, s, modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 		}
 
@@ -353,11 +387,11 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 
 		for _, sp := range currentSignal {
 			ch <- prometheus.MustNewConstMetric(
-				rssi, prometheus.GaugeValue, sp.Rssi, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				rssi, prometheus.GaugeValue, sp.Rssi, modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 
 			ch <- prometheus.MustNewConstMetric(
-				rsrp, prometheus.GaugeValue, sp.Rsrp, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
+				rsrp, prometheus.GaugeValue, sp.Rsrp, modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, rat,
 			)
 		}
 
@@ -371,7 +405,7 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 
 }
 
-func influxHandler(mmgr modemmanager.ModemManager) http.HandlerFunc {
+func influxHandler(mmgr modemmanager.ModemManager, config *safeConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		modems, err := mmgr.GetModems()
 		if err != nil {
@@ -452,9 +486,11 @@ func influxHandler(mmgr modemmanager.ModemManager) http.HandlerFunc {
 				}
 			}
 
+			modemName, port := modemIdentity(modem, imei, config)
+
 			tags := fmt.Sprintf(
-				"imei=%s,icc=%s,imsi=%s,operatorid=%s,operator=%s,v_operator=%s,mcc=%s,mnc=%s,rat=%s,phone_number=%s",
-				imei, simIdent, simImsi, simOpIdent, simOp, opName, mcc, mnc, rat, phoneNumber,
+				"modem=%s,port=%s,imei=%s,icc=%s,imsi=%s,operatorid=%s,operator=%s,v_operator=%s,mcc=%s,mnc=%s,rat=%s,phone_number=%s",
+				modemName, port, imei, simIdent, simImsi, simOpIdent, simOp, opName, mcc, mnc, rat, phoneNumber,
 			)
 			timestamp := time.Now().UnixNano()
 
@@ -511,6 +547,10 @@ func influxHandler(mmgr modemmanager.ModemManager) http.HandlerFunc {
 				isConnected = state == modemmanager.MmModemStateConnected
 			}
 
+			if stateStr == "Registered" {
+				connectIfConfigured(modem, imei, config)
+			}
+
 			opCode, err := modem3gpp.GetOperatorCode()
 			var opCodeInt int64
 			if err != nil {
@@ -583,6 +623,8 @@ func influxHandler(mmgr modemmanager.ModemManager) http.HandlerFunc {
 				}
 			}
 
+			influxSimSlotLines(w, modem, imei, timestamp)
+
 			bearers, _ := modem.GetBearers()
 			for _, bearer := range bearers {
 				bearerType, err := bearer.GetBearerType()
@@ -637,10 +679,20 @@ func main() {
 
 	log.Printf("Starting modem exporter using ModemManager v%s", version)
 
-	exporter := NewExporter(mmgr)
+	config := &safeConfig{C: &Config{}}
+	if *configFile != "" {
+		if err := config.Reload(*configFile); err != nil {
+			log.Fatal("error loading config file: ", err)
+		}
+		go watchConfig(config, *configFile)
+	}
+
+	exporter := NewExporter(mmgr, config)
 
 	promRegistry := prometheus.NewRegistry()
 	promRegistry.MustRegister(exporter)
+	promRegistry.MustRegister(configReloadsTotal)
+	promRegistry.MustRegister(scrapeDurationSeconds)
 
 	http.Handle(*metricsPath, promhttp.HandlerFor(promRegistry, promhttp.HandlerOpts{
 		EnableOpenMetrics: false,
@@ -655,6 +707,9 @@ func main() {
              </body>
              </html>`))
 	})
-	http.HandleFunc("/influx", influxHandler(mmgr))
+	http.HandleFunc("/influx", influxHandler(mmgr, config))
+	http.HandleFunc("/sim/switch", simSwitchHandler(mmgr))
+	http.HandleFunc("/rat/set", ratSetHandler(mmgr))
+	http.HandleFunc("/probe", probeHandler(exporter, config))
 	log.Fatal(http.ListenAndServe(*listenAddress, nil))
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// maxConcurrentProbes bounds how many scrapes can be serving
+	// metrics at once, in the style of ipmi_exporter's worker pool, so
+	// a burst of scrapes can't starve the exporter.
+	maxConcurrentProbes = 10
+
+	defaultProbeTimeout = 10 * time.Second
+)
+
+var (
+	probeSemaphore = make(chan struct{}, maxConcurrentProbes)
+
+	scrapeDurationSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "scrape_duration_seconds",
+			Help:      "Time taken to serve a /probe request for a target",
+		},
+		[]string{"target"},
+	)
+)
+
+// probeTimeout honors the X-Prometheus-Scrape-Timeout-Seconds header
+// set by the Prometheus server on every scrape request, the same
+// convention blackbox_exporter and ipmi_exporter use to avoid being
+// killed by the scrape timeout mid-probe.
+func probeTimeout(r *http.Request) time.Duration {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header == "" {
+		return defaultProbeTimeout
+	}
+
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		return defaultProbeTimeout
+	}
+
+	// Leave a little headroom so our response reaches Prometheus
+	// before it gives up waiting for it.
+	return time.Duration(seconds * 0.9 * float64(time.Second))
+}
+
+// bufferedResponse captures a promhttp handler's output in memory so
+// probeHandler can discard it if the probe outruns its deadline,
+// instead of racing a timeout response against a half-written one on
+// the real http.ResponseWriter.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+func (b *bufferedResponse) WriteHeader(status int) { b.status = status }
+
+func (b *bufferedResponse) copyTo(w http.ResponseWriter) {
+	for key, values := range b.header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+// probeHandler implements /probe?target=<name>. Unlike ipmi_exporter and
+// blackbox_exporter, this cannot actually dial a different ModemManager
+// instance per target: github.com/tete1030/go-modemmanager (as pinned,
+// v0.1.6) has no way to reach anything but the local D-Bus system bus —
+// NewModemManager takes no arguments and always connects to it, and
+// ModemManager has no Close method to tear a connection back down even
+// if one could be opened elsewhere. So probeHandler reuses the same
+// long-lived exporter main() already built for /metrics, which keeps
+// bearerAccumulator's reset-safety working across scrapes, and it
+// rejects any target listed under the config file's "targets" section
+// (which can only mean "this was meant to be remote") with 501 rather
+// than silently scraping the local modem under that label. Until
+// go-modemmanager grows a real remote-dial capability, /probe is only
+// useful for local modems, scraped the same way /metrics is.
+//
+// probeTimeout bounds how long the HTTP response can take: Collect has
+// no cancellation hook of its own (it's a plain prometheus.Collector
+// method, and a chunk of its per-modem work is a hardcoded
+// time.Sleep), so the collection runs in a goroutine against a
+// buffered response and probeHandler answers with 504 the moment the
+// deadline passes rather than leaving Prometheus hanging past its own
+// scrape_timeout. The abandoned goroutine finishes in the background.
+func probeHandler(exporter *Exporter, config *safeConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		if config.isRemoteTarget(target) {
+			http.Error(w, "probing a remote target is not supported by the pinned go-modemmanager dependency (no remote dial capability)", http.StatusNotImplemented)
+			return
+		}
+
+		select {
+		case probeSemaphore <- struct{}{}:
+			defer func() { <-probeSemaphore }()
+		case <-r.Context().Done():
+			http.Error(w, "probe queue full and request context expired", http.StatusServiceUnavailable)
+			return
+		}
+
+		start := time.Now()
+		timeout := probeTimeout(r)
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(exporter)
+
+		buf := newBufferedResponse()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(buf, r)
+		}()
+
+		select {
+		case <-done:
+			buf.copyTo(w)
+		case <-ctx.Done():
+			http.Error(w, fmt.Sprintf("probe of target %q timed out after %s", target, timeout), http.StatusGatewayTimeout)
+		}
+
+		scrapeDurationSeconds.WithLabelValues(target).Set(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbeTimeout(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{"no header", "", defaultProbeTimeout},
+		{"invalid header", "not-a-number", defaultProbeTimeout},
+		{"zero seconds", "0", defaultProbeTimeout},
+		{"negative seconds", "-5", defaultProbeTimeout},
+		{"ten seconds", "10", 9 * time.Second},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/probe?target=x", nil)
+			if tc.header != "" {
+				r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tc.header)
+			}
+
+			if got := probeTimeout(r); got != tc.want {
+				t.Errorf("probeTimeout() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
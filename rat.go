@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tete1030/go-modemmanager"
+)
+
+var (
+	ratLabels  = []string{"imei", "rat"}
+	bandLabels = []string{"imei", "band"}
+
+	ratSupported = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "rat_supported"),
+		"Whether the modem supports this access technology",
+		ratLabels, nil,
+	)
+
+	ratPreferred = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "rat_preferred"),
+		"Whether this access technology is the modem's preferred one among those currently allowed",
+		ratLabels, nil,
+	)
+
+	ratCurrent = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "rat_current"),
+		"Whether this access technology is currently allowed on the modem",
+		ratLabels, nil,
+	)
+
+	bandCurrent = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "band_current"),
+		"Whether this band is currently enabled on the modem",
+		bandLabels, nil,
+	)
+
+	// ratModeByName maps the access-technology names accepted by
+	// /rat/set and reported in rat labels to the MMModemMode bitmask
+	// ModemManager expects. The pinned github.com/tete1030/go-modemmanager
+	// dependency (as of v0.1.6) has no 5G NR bit in MMModemMode at all,
+	// so "5gnr" is not offered here; the finest lock available is LTE.
+	ratModeByName = map[string]modemmanager.MMModemMode{
+		"gsm":  modemmanager.MmModemMode2g,
+		"umts": modemmanager.MmModemMode3g,
+		"lte":  modemmanager.MmModemMode4g,
+	}
+
+	// ratNameOrder fixes the iteration order used when emitting
+	// per-rat metrics so scrapes are stable.
+	ratNameOrder = []string{"gsm", "umts", "lte"}
+)
+
+// combineModes ORs a slice of MMModemMode values into a single bitmask,
+// the shape both GetSupportedModes (a list of allowed-mode combinations)
+// and GetCurrentModes (a single combination) return.
+func combineModes(modes []modemmanager.MMModemMode) modemmanager.MMModemMode {
+	var mask modemmanager.MMModemMode
+	for _, mode := range modes {
+		mask |= mode
+	}
+	return mask
+}
+
+// ratModeActive reports whether mode is set in mask.
+func ratModeActive(mask, mode modemmanager.MMModemMode) bool {
+	return mask&mode != 0
+}
+
+// collectRatAndBand emits modem_rat_supported/preferred/current and
+// modem_band_current for modem.
+func collectRatAndBand(ch chan<- prometheus.Metric, modem modemmanager.Modem, imei string) {
+	supportedModes, err := modem.GetSupportedModes()
+	if err != nil {
+		log.Println("error getting supported modes:", err)
+	} else {
+		var supportedMask modemmanager.MMModemMode
+		for _, combination := range supportedModes {
+			supportedMask |= combineModes(combination.AllowedModes)
+		}
+		for _, name := range ratNameOrder {
+			supported := 0.0
+			if ratModeActive(supportedMask, ratModeByName[name]) {
+				supported = 1
+			}
+			ch <- prometheus.MustNewConstMetric(ratSupported, prometheus.GaugeValue, supported, imei, name)
+		}
+	}
+
+	current, err := modem.GetCurrentModes()
+	if err != nil {
+		log.Println("error getting current modes:", err)
+		return
+	}
+
+	allowed := combineModes(current.AllowedModes)
+
+	for _, name := range ratNameOrder {
+		mode := ratModeByName[name]
+
+		isCurrent := 0.0
+		if ratModeActive(allowed, mode) {
+			isCurrent = 1
+		}
+		ch <- prometheus.MustNewConstMetric(ratCurrent, prometheus.GaugeValue, isCurrent, imei, name)
+
+		isPreferred := 0.0
+		if ratModeActive(current.PreferredMode, mode) {
+			isPreferred = 1
+		}
+		ch <- prometheus.MustNewConstMetric(ratPreferred, prometheus.GaugeValue, isPreferred, imei, name)
+	}
+
+	bands, err := modem.GetCurrentBands()
+	if err != nil {
+		log.Println("error getting current bands:", err)
+		return
+	}
+	for _, band := range bands {
+		ch <- prometheus.MustNewConstMetric(bandCurrent, prometheus.GaugeValue, 1, imei, band.String())
+	}
+}
+
+// parseRatModes parses the comma-separated rat names in allowedParam and
+// the single rat name in preferredParam (if any) into the MMModemMode
+// bitmasks ratSetHandler passes to SetCurrentModes, rejecting any name
+// not found in ratModeByName.
+func parseRatModes(allowedParam, preferredParam string) (allowed, preferred modemmanager.MMModemMode, err error) {
+	for _, name := range strings.Split(allowedParam, ",") {
+		mode, ok := ratModeByName[strings.TrimSpace(name)]
+		if !ok {
+			return 0, 0, fmt.Errorf("unknown rat in allowed: %s", name)
+		}
+		allowed |= mode
+	}
+
+	if preferredParam != "" {
+		mode, ok := ratModeByName[preferredParam]
+		if !ok {
+			return 0, 0, fmt.Errorf("unknown rat in preferred: %s", preferredParam)
+		}
+		preferred = mode
+	}
+
+	return allowed, preferred, nil
+}
+
+// ratSetHandler handles POST /rat/set?imei=...&allowed=gsm,lte&preferred=lte,
+// locking a modem's access technology to a specific generation or set
+// of generations (e.g. to force 3G fallback for a flaky M2M link).
+func ratSetHandler(mmgr modemmanager.ModemManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		imei := r.URL.Query().Get("imei")
+		allowedParam := r.URL.Query().Get("allowed")
+		preferredParam := r.URL.Query().Get("preferred")
+		if imei == "" || allowedParam == "" {
+			http.Error(w, "imei and allowed are required", http.StatusBadRequest)
+			return
+		}
+
+		allowed, preferred, err := parseRatModes(allowedParam, preferredParam)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		modem, err := findModemByImei(mmgr, imei)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		var zeroMode modemmanager.MMModemMode
+		newMode := modemmanager.Mode{
+			AllowedModes:  zeroMode.BitmaskToSlice(uint32(allowed)),
+			PreferredMode: preferred,
+		}
+
+		if err := modem.SetCurrentModes(newMode); err != nil {
+			http.Error(w, "error setting current modes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte(fmt.Sprintf("modem %s rat set: allowed=%s preferred=%s\n", imei, allowedParam, preferredParam)))
+	}
+}
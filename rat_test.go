@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tete1030/go-modemmanager"
+)
+
+func TestCombineModes(t *testing.T) {
+	got := combineModes([]modemmanager.MMModemMode{modemmanager.MmModemMode2g, modemmanager.MmModemMode4g})
+	want := modemmanager.MmModemMode2g | modemmanager.MmModemMode4g
+	if got != want {
+		t.Fatalf("combineModes() = %v, want %v", got, want)
+	}
+
+	if got := combineModes(nil); got != 0 {
+		t.Fatalf("combineModes(nil) = %v, want 0", got)
+	}
+}
+
+func TestRatModeActive(t *testing.T) {
+	mask := modemmanager.MmModemMode2g | modemmanager.MmModemMode4g
+
+	if !ratModeActive(mask, modemmanager.MmModemMode2g) {
+		t.Error("ratModeActive(mask, 2g) = false, want true")
+	}
+	if ratModeActive(mask, modemmanager.MmModemMode3g) {
+		t.Error("ratModeActive(mask, 3g) = true, want false")
+	}
+}
+
+func TestParseRatModes(t *testing.T) {
+	cases := []struct {
+		name           string
+		allowedParam   string
+		preferredParam string
+		wantAllowed    modemmanager.MMModemMode
+		wantPreferred  modemmanager.MMModemMode
+		wantErr        bool
+	}{
+		{
+			name:          "single allowed, no preferred",
+			allowedParam:  "gsm",
+			wantAllowed:   modemmanager.MmModemMode2g,
+			wantPreferred: 0,
+		},
+		{
+			name:          "multiple allowed with spaces",
+			allowedParam:  "gsm, lte",
+			wantAllowed:   modemmanager.MmModemMode2g | modemmanager.MmModemMode4g,
+			wantPreferred: 0,
+		},
+		{
+			name:           "allowed and preferred",
+			allowedParam:   "gsm,umts,lte",
+			preferredParam: "lte",
+			wantAllowed:    modemmanager.MmModemMode2g | modemmanager.MmModemMode3g | modemmanager.MmModemMode4g,
+			wantPreferred:  modemmanager.MmModemMode4g,
+		},
+		{
+			name:         "unknown allowed rat",
+			allowedParam: "5gnr",
+			wantErr:      true,
+		},
+		{
+			name:           "unknown preferred rat",
+			allowedParam:   "gsm",
+			preferredParam: "5gnr",
+			wantErr:        true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			allowed, preferred, err := parseRatModes(tc.allowedParam, tc.preferredParam)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("parseRatModes() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRatModes() unexpected error: %v", err)
+			}
+			if allowed != tc.wantAllowed {
+				t.Errorf("allowed = %v, want %v", allowed, tc.wantAllowed)
+			}
+			if preferred != tc.wantPreferred {
+				t.Errorf("preferred = %v, want %v", preferred, tc.wantPreferred)
+			}
+		})
+	}
+}
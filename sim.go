@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tete1030/go-modemmanager"
+)
+
+const (
+	// These are ModemManager's documented D-Bus Modem/Sim properties
+	// and method (see mmcli -m's "SIM slots" section, and MM >= 1.20
+	// for Sim.Eid), but github.com/tete1030/go-modemmanager (as pinned,
+	// v0.1.6) has no Go binding for any of them, so they're called
+	// directly over D-Bus instead of through the wrapper — the same
+	// bus and object path the wrapper itself dials, reached the same
+	// way bearer.go already reaches GetObjectPath() to track which
+	// bearer an accumulator series belongs to.
+	modemPropertySimSlots        = modemmanager.ModemInterface + ".SimSlots"
+	modemPropertyPrimarySimSlot  = modemmanager.ModemInterface + ".PrimarySimSlot"
+	modemMethodSetPrimarySimSlot = modemmanager.ModemInterface + ".SetPrimarySimSlot"
+	simPropertyEid               = modemmanager.SimInterface + ".Eid"
+
+	// noSimObjectPath is the sentinel SimSlots uses in place of a real
+	// object path for a slot with no SIM card inserted.
+	noSimObjectPath = dbus.ObjectPath("/")
+
+	// simSwitchPollInterval/simSwitchPollTimeout bound how long
+	// simSwitchHandler waits for the modem to re-register after a
+	// slot switch before responding anyway.
+	simSwitchPollInterval = 500 * time.Millisecond
+	simSwitchPollTimeout  = 10 * time.Second
+)
+
+var (
+	simSlotLabels = []string{"imei", "slot", "iccid", "eid"}
+
+	simSlotActive = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "sim_slot_active"),
+		"Is this SIM slot the modem's currently active/primary slot",
+		simSlotLabels, nil,
+	)
+
+	simSlotPresent = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "sim_slot_present"),
+		"Is a SIM card present in this slot",
+		simSlotLabels, nil,
+	)
+
+	simSlotConfigured = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "sim_slot_configured"),
+		"The sim_slot configured for this modem, for comparison against modem_sim_slot_active",
+		[]string{"imei"}, nil,
+	)
+)
+
+// simSlots reads a modem's SimSlots and PrimarySimSlot properties,
+// returning the ordered list of per-slot Sim object paths (slot N is
+// slots[N-1], using noSimObjectPath for an empty slot) and the 1-based
+// index of the currently active slot.
+func simSlots(modemPath dbus.ObjectPath) (slots []dbus.ObjectPath, primary uint32, err error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	obj := conn.Object(modemmanager.ModemManagerInterface, modemPath)
+
+	slotsVariant, err := obj.GetProperty(modemPropertySimSlots)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting %s: %w", modemPropertySimSlots, err)
+	}
+	slots, ok := slotsVariant.Value().([]dbus.ObjectPath)
+	if !ok {
+		return nil, 0, fmt.Errorf("unexpected type for %s: %T", modemPropertySimSlots, slotsVariant.Value())
+	}
+
+	primaryVariant, err := obj.GetProperty(modemPropertyPrimarySimSlot)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting %s: %w", modemPropertyPrimarySimSlot, err)
+	}
+	primary, ok = primaryVariant.Value().(uint32)
+	if !ok {
+		return nil, 0, fmt.Errorf("unexpected type for %s: %T", modemPropertyPrimarySimSlot, primaryVariant.Value())
+	}
+
+	return slots, primary, nil
+}
+
+// simEid reads a SIM's Eid property (its eUICC identifier, set only
+// for eSIM profiles) directly over D-Bus, since Sim has no GetEid
+// binding in the pinned dependency.
+func simEid(simPath dbus.ObjectPath) (string, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return "", err
+	}
+
+	variant, err := conn.Object(modemmanager.ModemManagerInterface, simPath).GetProperty(simPropertyEid)
+	if err != nil {
+		return "", fmt.Errorf("getting %s: %w", simPropertyEid, err)
+	}
+
+	eid, ok := variant.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected type for %s: %T", simPropertyEid, variant.Value())
+	}
+	return eid, nil
+}
+
+// setPrimarySimSlot calls a modem's SetPrimarySimSlot method directly
+// over D-Bus, since Modem has no binding for it in the pinned
+// dependency.
+func setPrimarySimSlot(modemPath dbus.ObjectPath, slot uint32) error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+
+	call := conn.Object(modemmanager.ModemManagerInterface, modemPath).Call(modemMethodSetPrimarySimSlot, 0, slot)
+	return call.Err
+}
+
+// collectSimSlots enumerates every SIM slot the modem reports and
+// publishes modem_sim_slot_present/active for each one. Modems that
+// report no SimSlots at all — single-SIM hardware, or ModemManager
+// older than 1.16, which predates multi-SIM support — fall back to
+// reporting the wrapper's one active SIM as slot "1".
+func collectSimSlots(ch chan<- prometheus.Metric, modem modemmanager.Modem, imei string, config *safeConfig) {
+	slots, primary, err := simSlots(modem.GetObjectPath())
+	if err != nil || len(slots) == 0 {
+		collectSingleActiveSimSlot(ch, modem, imei)
+	} else {
+		for i, simPath := range slots {
+			slotNumber := uint32(i + 1)
+			emitSimSlot(ch, imei, slotNumber, simPath, slotNumber == primary)
+		}
+	}
+
+	port, _ := resolvePortAddress(modem)
+	if modemConfig, ok := config.lookup(port, imei); ok && modemConfig.SIMSlot != 0 {
+		ch <- prometheus.MustNewConstMetric(simSlotConfigured, prometheus.GaugeValue, float64(modemConfig.SIMSlot), imei)
+	}
+}
+
+// collectSingleActiveSimSlot is the fallback collectSimSlots uses when
+// a modem has no SimSlots property to enumerate.
+func collectSingleActiveSimSlot(ch chan<- prometheus.Metric, modem modemmanager.Modem, imei string) {
+	sim, err := modem.GetSim()
+	if err != nil {
+		return
+	}
+
+	emitSimSlot(ch, imei, 1, sim.GetObjectPath(), true)
+}
+
+// emitSimSlot writes modem_sim_slot_present/active for a single slot,
+// given the Sim object path ModemManager reports for it (or
+// noSimObjectPath if the slot is empty).
+func emitSimSlot(ch chan<- prometheus.Metric, imei string, slotNumber uint32, simPath dbus.ObjectPath, active bool) {
+	slot := strconv.FormatUint(uint64(slotNumber), 10)
+	present := simPath != noSimObjectPath && simPath != ""
+
+	var iccid, eid string
+	if present {
+		if sim, err := modemmanager.NewSim(simPath); err == nil {
+			iccid, _ = sim.GetSimIdentifier()
+		}
+		eid, _ = simEid(simPath)
+	}
+
+	presentValue := 0.0
+	if present {
+		presentValue = 1
+	}
+	activeValue := 0.0
+	if active {
+		activeValue = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(simSlotPresent, prometheus.GaugeValue, presentValue, imei, slot, iccid, eid)
+	ch <- prometheus.MustNewConstMetric(simSlotActive, prometheus.GaugeValue, activeValue, imei, slot, iccid, eid)
+}
+
+// influxSimSlotLines writes one modem_sim line per SIM slot, with the
+// same present/active/iccid/eid fields collectSimSlots exports.
+func influxSimSlotLines(w http.ResponseWriter, modem modemmanager.Modem, imei string, timestamp int64) {
+	slots, primary, err := simSlots(modem.GetObjectPath())
+	if err != nil || len(slots) == 0 {
+		sim, err := modem.GetSim()
+		if err != nil {
+			return
+		}
+		writeInfluxSimSlotLine(w, imei, 1, sim.GetObjectPath(), true, timestamp)
+		return
+	}
+
+	for i, simPath := range slots {
+		slotNumber := uint32(i + 1)
+		writeInfluxSimSlotLine(w, imei, slotNumber, simPath, slotNumber == primary, timestamp)
+	}
+}
+
+func writeInfluxSimSlotLine(w http.ResponseWriter, imei string, slotNumber uint32, simPath dbus.ObjectPath, active bool, timestamp int64) {
+	present := simPath != noSimObjectPath && simPath != ""
+
+	var iccid, eid string
+	if present {
+		if sim, err := modemmanager.NewSim(simPath); err == nil {
+			iccid, _ = sim.GetSimIdentifier()
+		}
+		eid, _ = simEid(simPath)
+	}
+
+	w.Write([]byte(fmt.Sprintf(
+		"modem_sim,imei=%s,slot=%d,iccid=%s,eid=%s present=%t,active=%t %d\n",
+		imei, slotNumber, iccid, eid, present, active, timestamp,
+	)))
+}
+
+// simSwitchHandler handles POST /sim/switch?imei=...&slot=N: it calls
+// SetPrimarySimSlot to make slot N primary, then polls the modem's
+// state until it re-registers (or simSwitchPollTimeout elapses) before
+// responding, since switching slots forces a re-registration with the
+// network.
+func simSwitchHandler(mmgr modemmanager.ModemManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		imei := r.URL.Query().Get("imei")
+		slotParam := r.URL.Query().Get("slot")
+		if imei == "" || slotParam == "" {
+			http.Error(w, "imei and slot are required", http.StatusBadRequest)
+			return
+		}
+
+		slot, err := strconv.ParseUint(slotParam, 10, 32)
+		if err != nil || slot == 0 {
+			http.Error(w, "slot must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		modem, err := findModemByImei(mmgr, imei)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err := setPrimarySimSlot(modem.GetObjectPath(), uint32(slot)); err != nil {
+			http.Error(w, "error setting primary sim slot: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		registered := waitForRegistration(modem, simSwitchPollTimeout)
+
+		w.Write([]byte(fmt.Sprintf("modem %s primary sim slot set to %d, registered=%t\n", imei, slot, registered)))
+	}
+}
+
+// waitForRegistration polls modem's state until it reaches Registered
+// or Connected, or until timeout elapses.
+func waitForRegistration(modem modemmanager.Modem, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		state, err := modem.GetState()
+		if err == nil && (state == modemmanager.MmModemStateRegistered || state == modemmanager.MmModemStateConnected) {
+			return true
+		}
+		time.Sleep(simSwitchPollInterval)
+	}
+	return false
+}
+
+// findModemByImei returns the modem matching imei, or an error if none
+// is found.
+func findModemByImei(mmgr modemmanager.ModemManager, imei string) (modemmanager.Modem, error) {
+	modems, err := mmgr.GetModems()
+	if err != nil {
+		return nil, fmt.Errorf("error getting modems: %w", err)
+	}
+
+	for _, modem := range modems {
+		modem3gpp, err := modem.Get3gpp()
+		if err != nil {
+			continue
+		}
+		modemImei, err := modem3gpp.GetImei()
+		if err != nil {
+			continue
+		}
+		if modemImei == imei {
+			return modem, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no modem found with imei %s", imei)
+}